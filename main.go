@@ -7,7 +7,11 @@ go run main.go -a -r WorkDir
 
 import (
 	"FindDuplicate/process"
+	"FindDuplicate/process/blocks"
+	"FindDuplicate/process/filter"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"sync"
 
@@ -15,13 +19,35 @@ import (
 )
 
 var (
-	flagR *bool
-	flagA *bool
+	flagR                *bool
+	flagA                *bool
+	flagOp               *string
+	flagDryRun           *bool
+	flagOutput           *string
+	flagFormat           *string
+	flagMinSize          *int64
+	flagMaxSize          *int64
+	flagFollowSymlinks   *bool
+	flagCrossFilesystems *bool
+	flagIgnoreFile       *string
+	flagBlockMode        *bool
+	flagBlockSize        *int
 )
 
 func main() {
 	flagR = flag.Bool("r", false, "Удаляет найденные дубликаты в подкаталогах")
 	flagA = flag.Bool("a", false, "Перед удалением не спрашивать подтверждения")
+	flagOp = flag.String("op", "report", "Операция над дубликатами: report, remove, hardlink, symlink, reflink")
+	flagDryRun = flag.Bool("dry-run", false, "Не выполнять операцию, а вывести эквивалентный shell-скрипт")
+	flagOutput = flag.String("o", "", "Файл для отчёта о найденных дубликатах (по умолчанию — stdout)")
+	flagFormat = flag.String("format", "", "Формат отчёта о найденных дубликатах: json, csv, fdupes")
+	flagMinSize = flag.Int64("min-size", 0, "Минимальный размер файла для сравнения, в байтах")
+	flagMaxSize = flag.Int64("max-size", 0, "Максимальный размер файла для сравнения, в байтах (0 - без ограничения)")
+	flagFollowSymlinks = flag.Bool("follow-symlinks", false, "Заходить внутрь симлинков на директории")
+	flagCrossFilesystems = flag.Bool("cross-filesystems", false, "Спускаться в поддиректории на других файловых системах")
+	flagIgnoreFile = flag.String("ignore-file", "", "Файл с gitignore-подобными правилами исключения файлов из сравнения")
+	flagBlockMode = flag.Bool("block-mode", false, "Сравнивать файлы поблочно (см. process/blocks) вместо полного хэширования — дешевле для больших файлов")
+	flagBlockSize = flag.Int("block-size", blocks.DefaultBlockSize, "Размер блока для -block-mode, в байтах")
 	flag.Parse()
 
 	log.SetFormatter(&log.JSONFormatter{})
@@ -39,9 +65,41 @@ func main() {
 		hLog.Fatal("Не верно указана стартовая директория")
 	}
 
+	op, err := process.OpParse(*flagOp)
+	if err != nil {
+		hLog.Fatal(err)
+	}
+
 	// Запуск обхода указанной директории
 	wg := sync.WaitGroup{}
-	options := process.OptionsNew(!*flagA, *flagR, 10, hLog)
+	options := process.OptionsNew(!*flagA, *flagR, 10, process.AlgorithmFull, hLog)
+	options.OpSet(op)
+	options.DryRunSet(*flagDryRun)
+	options.MinSizeSet(*flagMinSize)
+	options.MaxSizeSet(*flagMaxSize)
+	options.FollowSymlinksSet(*flagFollowSymlinks)
+	options.CrossFilesystemsSet(*flagCrossFilesystems)
+	options.BlockModeSet(*flagBlockMode)
+	options.BlockSizeSet(*flagBlockSize)
+
+	if *flagIgnoreFile != "" {
+		matcher, err := filter.LoadMatcherFile(*flagIgnoreFile)
+		if err != nil {
+			hLog.Fatal(err)
+		}
+
+		options.MatcherSet(matcher)
+	}
+
+	if *flagFormat != "" {
+		reporter, closeReport, err := newReporter(*flagFormat, *flagOutput)
+		if err != nil {
+			hLog.Fatal(err)
+		}
+		defer closeReport()
+
+		options.ReporterSet(reporter)
+	}
 
 	err = process.StartWatch(options, fDir, &wg)
 	if err != nil {
@@ -50,3 +108,32 @@ func main() {
 
 	hLog.WithFields(log.Fields{"main": "block"}).Info("Finish")
 }
+
+// newReporter возвращает process.Reporter для формата sFormat (json, csv,
+// fdupes), пишущий в файл sOutput, либо в stdout, если sOutput пуст.
+// Возвращаемую closeReport нужно вызвать после завершения сканирования.
+func newReporter(sFormat string, sOutput string) (reporter process.Reporter, closeReport func(), err error) {
+	w := io.Writer(os.Stdout)
+	closeReport = func() {}
+
+	if sOutput != "" {
+		f, err := os.Create(sOutput)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		w = f
+		closeReport = func() { f.Close() }
+	}
+
+	switch sFormat {
+	case "json":
+		return process.NewJSONReporter(w), closeReport, nil
+	case "csv":
+		return process.NewCSVReporter(w), closeReport, nil
+	case "fdupes":
+		return process.NewFdupesReporter(w), closeReport, nil
+	default:
+		return nil, nil, fmt.Errorf("неизвестный формат отчёта %q (допустимо: json, csv, fdupes)", sFormat)
+	}
+}
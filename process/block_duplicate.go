@@ -0,0 +1,62 @@
+package process
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"FindDuplicate/process/blocks"
+)
+
+// blockGroupFind — аггрегатор, заменяющий хэш-пайплайн duplicateGroupFind в
+// режиме Options.BlockModeGet: внутри одной группы файлов-кандидатов с
+// одинаковым размером файлы сравниваются поблочно (blocks.BlocksEqual) и
+// распределяются по кластерам совпадающих файлов. Файлы, для которых блоки
+// не были вычислены (см. StartContentChanges), в сравнение не попадают.
+// Возвращаются итоговые кластеры из >= 2 файлов.
+func blockGroupFind(options *Options, sizeGroup []FindDuplicate) [][]FindDuplicate {
+	type cluster struct {
+		rep     FindDuplicate
+		members []FindDuplicate
+	}
+
+	clusters := make([]*cluster, 0)
+
+	for _, fd := range sizeGroup {
+		if fd.Blocks == nil {
+			continue
+		}
+
+		placed := false
+		for _, c := range clusters {
+			equal, err := blocks.BlocksEqual(
+				c.rep.DirName+"/"+c.rep.FileName, c.rep.Blocks,
+				fd.DirName+"/"+fd.FileName, fd.Blocks,
+			)
+			if err != nil {
+				options.hLog.WithFields(logrus.Fields{"blockGroupFind": ""}).
+					Errorf("ошибка блочного сравнения файла %s: %s", fd.DirName+"/"+fd.FileName, err)
+				continue
+			}
+
+			if equal {
+				c.members = append(c.members, fd)
+				placed = true
+				break
+			}
+		}
+
+		if !placed {
+			clusters = append(clusters, &cluster{rep: fd, members: []FindDuplicate{fd}})
+		}
+	}
+
+	result := make([][]FindDuplicate, 0, len(clusters))
+	for _, c := range clusters {
+		if len(c.members) < 2 {
+			continue
+		}
+
+		result = append(result, c.members)
+	}
+
+	return result
+}
@@ -0,0 +1,120 @@
+// Пакет blocks реализует блочное сравнение файлов, используемое в
+// process.Options.BlockMode для больших файлов, у которых полное хэширование
+// слишком дорого. Файл делится на блоки фиксированного размера; для каждого
+// блока сразу считается дешёвый слабый хэш (adler-32), а дорогой строгий хэш
+// (SHA-256) считается лениво — только для тех блоков, у которых слабые хэши
+// уже совпали.
+package blocks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash/adler32"
+	"io"
+	"os"
+)
+
+// DefaultBlockSize — размер блока по умолчанию
+const DefaultBlockSize = 128 * 1024
+
+// BlockInfo описывает один блок файла
+type BlockInfo struct {
+	Offset   int64
+	Size     int
+	WeakHash uint32
+	// Hash — строгий хэш блока (SHA-256). Заполняется лениво функцией
+	// StrongHash и до первого обращения к ней равен nil.
+	Hash []byte
+}
+
+// Split читает файл sPath и делит его на блоки размером blockSize (кроме,
+// возможно, последнего блока), сразу вычисляя слабый хэш каждого блока.
+// Строгий хэш не считается — он вычисляется лениво через (*BlockInfo).StrongHash.
+func Split(sPath string, blockSize int) ([]BlockInfo, error) {
+	f, err := os.Open(sPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, blockSize)
+	result := make([]BlockInfo, 0)
+	var offset int64
+
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			result = append(result, BlockInfo{
+				Offset:   offset,
+				Size:     n,
+				WeakHash: adler32.Checksum(buf[:n]),
+			})
+			offset += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// StrongHash возвращает строгий хэш (SHA-256) блока b внутри файла sPath,
+// вычисляя и кэшируя его в b.Hash при первом вызове.
+func (b *BlockInfo) StrongHash(sPath string) ([]byte, error) {
+	if b.Hash != nil {
+		return b.Hash, nil
+	}
+
+	f, err := os.Open(sPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, b.Size)
+	if _, err := f.ReadAt(buf, b.Offset); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(buf)
+	b.Hash = sum[:]
+
+	return b.Hash, nil
+}
+
+// BlocksEqual сравнивает блоки двух файлов: сначала по числу блоков, затем
+// поблочно — сначала дешёвые слабые хэши, и только при их совпадении
+// дорогие строгие хэши (вычисляемые лениво через StrongHash). Первое же
+// несовпадение слабого или строгого хэша прерывает сравнение.
+func BlocksEqual(path1 string, blocks1 []BlockInfo, path2 string, blocks2 []BlockInfo) (bool, error) {
+	if len(blocks1) != len(blocks2) {
+		return false, nil
+	}
+
+	for i := range blocks1 {
+		if blocks1[i].WeakHash != blocks2[i].WeakHash {
+			return false, nil
+		}
+
+		hash1, err := blocks1[i].StrongHash(path1)
+		if err != nil {
+			return false, err
+		}
+
+		hash2, err := blocks2[i].StrongHash(path2)
+		if err != nil {
+			return false, err
+		}
+
+		if !bytes.Equal(hash1, hash2) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
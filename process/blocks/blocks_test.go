@@ -0,0 +1,125 @@
+package blocks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func mustWriteFile(t *testing.T, dir, name string, body []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		t.Fatalf("ошибка записи файла %s: %s", path, err)
+	}
+
+	return path
+}
+
+// TestSplit проверяет разбиение на блоки с неполным последним блоком и
+// вычисление слабого хэша для каждого из них.
+func TestSplit(t *testing.T) {
+	dir := t.TempDir()
+	path := mustWriteFile(t, dir, "f.bin", []byte("aaaabbbbcc"))
+
+	got, err := Split(path, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("ожидалось 3 блока (4+4+2 байта), получено %d", len(got))
+	}
+	if got[0].Size != 4 || got[1].Size != 4 || got[2].Size != 2 {
+		t.Fatalf("неверные размеры блоков: %+v", got)
+	}
+	if got[0].Offset != 0 || got[1].Offset != 4 || got[2].Offset != 8 {
+		t.Fatalf("неверные смещения блоков: %+v", got)
+	}
+	if got[2].Hash != nil {
+		t.Fatal("строгий хэш должен вычисляться лениво, а не при Split")
+	}
+}
+
+// TestBlocksEqualSameContent проверяет, что файлы с одинаковым содержимым
+// (и одинаковым числом блоков) признаются поблочно равными.
+func TestBlocksEqualSameContent(t *testing.T) {
+	dir := t.TempDir()
+	body := []byte("aaaabbbbcccc")
+
+	pathA := mustWriteFile(t, dir, "a.bin", body)
+	pathB := mustWriteFile(t, dir, "b.bin", body)
+
+	blocksA, err := Split(pathA, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+	blocksB, err := Split(pathB, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+
+	equal, err := BlocksEqual(pathA, blocksA, pathB, blocksB)
+	if err != nil {
+		t.Fatalf("ошибка BlocksEqual: %s", err)
+	}
+	if !equal {
+		t.Fatal("файлы с одинаковым содержимым должны быть равны поблочно")
+	}
+}
+
+// TestBlocksEqualDifferentContent проверяет, что различие в одном блоке
+// (при совпадающем числе и размере остальных блоков) даёт неравенство —
+// слабые хэши последнего блока расходятся, и сравнение завершается, не
+// доходя до строгих хэшей.
+func TestBlocksEqualDifferentContent(t *testing.T) {
+	dir := t.TempDir()
+
+	pathA := mustWriteFile(t, dir, "a.bin", []byte("aaaabbbbcccc"))
+	pathB := mustWriteFile(t, dir, "b.bin", []byte("aaaabbbbdddd"))
+
+	blocksA, err := Split(pathA, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+	blocksB, err := Split(pathB, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+
+	equal, err := BlocksEqual(pathA, blocksA, pathB, blocksB)
+	if err != nil {
+		t.Fatalf("ошибка BlocksEqual: %s", err)
+	}
+	if equal {
+		t.Fatal("файлы с разным содержимым не должны быть равны поблочно")
+	}
+}
+
+// TestBlocksEqualDifferentBlockCount проверяет, что файлы с разным числом
+// блоков (то есть разной длины) сразу признаются неравными, без сравнения
+// хэшей.
+func TestBlocksEqualDifferentBlockCount(t *testing.T) {
+	dir := t.TempDir()
+
+	pathA := mustWriteFile(t, dir, "a.bin", []byte("aaaabbbb"))
+	pathB := mustWriteFile(t, dir, "b.bin", []byte("aaaabbbbcccc"))
+
+	blocksA, err := Split(pathA, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+	blocksB, err := Split(pathB, 4)
+	if err != nil {
+		t.Fatalf("ошибка Split: %s", err)
+	}
+
+	equal, err := BlocksEqual(pathA, blocksA, pathB, blocksB)
+	if err != nil {
+		t.Fatalf("ошибка BlocksEqual: %s", err)
+	}
+	if equal {
+		t.Fatal("файлы с разным числом блоков не должны быть равны поблочно")
+	}
+}
@@ -0,0 +1,188 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Op задаёт операцию, которая применяется к найденному файлу-дубликату
+type Op uint8
+
+const (
+	// OpReport — дубликаты только перечисляются, файловая система не меняется
+	OpReport Op = iota
+	// OpRemove — дубликат удаляется (старое поведение)
+	OpRemove
+	// OpHardlink — дубликат заменяется жёсткой ссылкой на оригинал
+	OpHardlink
+	// OpSymlink — дубликат заменяется символьной ссылкой на оригинал
+	OpSymlink
+	// OpReflink — дубликат заменяется copy-on-write клоном оригинала
+	// (ioctl_ficlone на Linux, clonefile на macOS), с откатом на обычное
+	// копирование там, где файловая система CoW-клоны не поддерживает
+	OpReflink
+)
+
+// String возвращает имя операции в том же виде, в котором его ожидает
+// флаг --op командной строки (см. OpParse)
+func (op Op) String() string {
+	switch op {
+	case OpRemove:
+		return "remove"
+	case OpHardlink:
+		return "hardlink"
+	case OpSymlink:
+		return "symlink"
+	case OpReflink:
+		return "reflink"
+	default:
+		return "report"
+	}
+}
+
+// OpParse разбирает имя операции, переданное через флаг --op, в Op
+func OpParse(s string) (Op, error) {
+	switch s {
+	case "", "report":
+		return OpReport, nil
+	case "remove":
+		return OpRemove, nil
+	case "hardlink":
+		return OpHardlink, nil
+	case "symlink":
+		return OpSymlink, nil
+	case "reflink":
+		return OpReflink, nil
+	default:
+		return OpReport, fmt.Errorf("неизвестная операция %q (допустимо: report, remove, hardlink, symlink, reflink)", s)
+	}
+}
+
+// Deduper применяет операцию над найденным дубликатом fd, для которого
+// original — оставляемый образец дубликата (первый файл в группе).
+type Deduper interface {
+	Dedupe(original FindDuplicate, fd FindDuplicate) error
+}
+
+// scriptDeduper умеет описать свою операцию одной строкой shell-скрипта —
+// это нужно dryRunDeduper, чтобы вместо реального выполнения операции
+// накопить эквивалентный скрипт для последующего запуска пользователем.
+type scriptDeduper interface {
+	Deduper
+	shellLine(original FindDuplicate, fd FindDuplicate) string
+}
+
+// NewDeduper возвращает реализацию Deduper для операции op. Если dryRun
+// истина, операция не выполняется — вместо этого в w построчно пишется
+// эквивалентный ей shell-скрипт (как log_script/run_script у fclones),
+// который пользователь может просмотреть и запустить сам.
+func NewDeduper(op Op, dryRun bool, w io.Writer) Deduper {
+	var d scriptDeduper
+
+	switch op {
+	case OpRemove:
+		d = removeDeduper{}
+	case OpHardlink:
+		d = hardlinkDeduper{}
+	case OpSymlink:
+		d = symlinkDeduper{}
+	case OpReflink:
+		d = reflinkDeduper{}
+	default:
+		d = reportDeduper{}
+	}
+
+	if dryRun {
+		return &scriptRecorder{inner: d, w: w}
+	}
+
+	return d
+}
+
+func sPath(fd FindDuplicate) string {
+	return fd.DirName + "/" + fd.FileName
+}
+
+// scriptRecorder оборачивает любой scriptDeduper и вместо применения
+// операции пишет её shell-эквивалент в w
+type scriptRecorder struct {
+	inner scriptDeduper
+	w     io.Writer
+}
+
+func (s *scriptRecorder) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	_, err := fmt.Fprintln(s.w, s.inner.shellLine(original, fd))
+	return err
+}
+
+// reportDeduper (OpReport) — файлы не меняются, дубликат только упоминается
+type reportDeduper struct{}
+
+func (reportDeduper) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	return nil
+}
+
+func (reportDeduper) shellLine(original FindDuplicate, fd FindDuplicate) string {
+	return fmt.Sprintf("# дубликат файла %q: %q", sPath(original), sPath(fd))
+}
+
+// removeDeduper (OpRemove) — дубликат удаляется
+type removeDeduper struct{}
+
+func (removeDeduper) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	return os.Remove(sPath(fd))
+}
+
+func (removeDeduper) shellLine(original FindDuplicate, fd FindDuplicate) string {
+	return fmt.Sprintf("rm -- %q", sPath(fd))
+}
+
+// hardlinkDeduper (OpHardlink) — дубликат заменяется жёсткой ссылкой на
+// оригинал. Поскольку жёсткая ссылка указывает на тот же inode, что и
+// оригинал, режим доступа файла автоматически совпадает с оригиналом.
+type hardlinkDeduper struct{}
+
+func (hardlinkDeduper) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	return replaceWithLink(sPath(original), sPath(fd), os.Link)
+}
+
+func (hardlinkDeduper) shellLine(original FindDuplicate, fd FindDuplicate) string {
+	return fmt.Sprintf("rm -- %q && ln -- %q %q", sPath(fd), sPath(original), sPath(fd))
+}
+
+// symlinkDeduper (OpSymlink) — дубликат заменяется символьной ссылкой на
+// оригинал. В отличие от жёсткой ссылки, режим доступа самой символьной
+// ссылки не имеет значения — права вычисляются по файлу, на который она
+// указывает, то есть по оригиналу.
+type symlinkDeduper struct{}
+
+func (symlinkDeduper) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	return replaceWithLink(sPath(original), sPath(fd), os.Symlink)
+}
+
+func (symlinkDeduper) shellLine(original FindDuplicate, fd FindDuplicate) string {
+	return fmt.Sprintf("rm -- %q && ln -s -- %q %q", sPath(fd), sPath(original), sPath(fd))
+}
+
+// replaceWithLink безопасно заменяет файл dupPath ссылкой на origPath,
+// созданной функцией linkFn (os.Link либо os.Symlink): сначала dupPath
+// переименовывается во временный файл, и только при успешном создании
+// ссылки временный файл удаляется. Если создание ссылки не удалось,
+// dupPath восстанавливается из временного файла.
+func replaceWithLink(origPath string, dupPath string, linkFn func(oldname string, newname string) error) error {
+	backupPath := dupPath + ".fdupbak"
+
+	if err := os.Rename(dupPath, backupPath); err != nil {
+		return err
+	}
+
+	if err := linkFn(origPath, dupPath); err != nil {
+		_ = os.Remove(dupPath)
+		_ = os.Rename(backupPath, dupPath)
+
+		return err
+	}
+
+	return os.Remove(backupPath)
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflinkDeduper (OpReflink) — дубликат заменяется copy-on-write клоном
+// оригинала через ioctl_ficlone. Если файловая система клоны не
+// поддерживает (например, EXDEV/ENOTSUP), клон откатывается на обычное
+// потоковое копирование.
+type reflinkDeduper struct{}
+
+func (reflinkDeduper) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	return replaceWithReflink(sPath(original), sPath(fd))
+}
+
+func (reflinkDeduper) shellLine(original FindDuplicate, fd FindDuplicate) string {
+	return fmt.Sprintf("rm -- %q && cp --reflink=auto -- %q %q", sPath(fd), sPath(original), sPath(fd))
+}
+
+func replaceWithReflink(origPath string, dupPath string) error {
+	backupPath := dupPath + ".fdupbak"
+
+	if err := os.Rename(dupPath, backupPath); err != nil {
+		return err
+	}
+
+	if err := cloneFile(origPath, dupPath); err != nil {
+		_ = os.Remove(dupPath)
+		_ = os.Rename(backupPath, dupPath)
+
+		return err
+	}
+
+	return os.Remove(backupPath)
+}
+
+// cloneFile пытается сделать reflink-клон srcPath в dstPath через
+// ioctl_ficlone, а если файловая система это не поддерживает — копирует
+// содержимое потоково.
+func cloneFile(srcPath string, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())); err == nil {
+		return nil
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+
+	return err
+}
@@ -0,0 +1,62 @@
+//go:build !linux && !darwin
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// reflinkDeduper (OpReflink) на платформах без поддержки reflink-клонов в
+// ядре (ioctl_ficlone на Linux, clonefile на macOS) откатывается на обычное
+// потоковое копирование.
+type reflinkDeduper struct{}
+
+func (reflinkDeduper) Dedupe(original FindDuplicate, fd FindDuplicate) error {
+	return replaceWithReflink(sPath(original), sPath(fd))
+}
+
+func (reflinkDeduper) shellLine(original FindDuplicate, fd FindDuplicate) string {
+	return fmt.Sprintf("rm -- %q && cp -- %q %q", sPath(fd), sPath(original), sPath(fd))
+}
+
+func replaceWithReflink(origPath string, dupPath string) error {
+	backupPath := dupPath + ".fdupbak"
+
+	if err := os.Rename(dupPath, backupPath); err != nil {
+		return err
+	}
+
+	if err := copyFile(origPath, dupPath); err != nil {
+		_ = os.Remove(dupPath)
+		_ = os.Rename(backupPath, dupPath)
+
+		return err
+	}
+
+	return os.Remove(backupPath)
+}
+
+func copyFile(srcPath string, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+
+	return err
+}
@@ -0,0 +1,171 @@
+// Пакет filter реализует отбор файлов и директорий по gitignore-подобным
+// шаблонам: "**" и "*" для произвольного числа символов (в том числе через
+// директории для "**"), "?" для одного символа, отрицание через "!" и
+// постраничное объединение правил — корневой список плюс правила из
+// .fdignore каждой вложенной директории.
+package filter
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// FdignoreFileName — имя файла с правилами отбора для отдельной директории,
+// по аналогии с .gitignore
+const FdignoreFileName = ".fdignore"
+
+// Matcher хранит набор правил отбора, уже готовых к проверке путей
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// NewMatcher разбирает строки в формате .gitignore в готовый Matcher.
+// Пустые строки и строки, начинающиеся с "#", пропускаются.
+func NewMatcher(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r, err := compileRule(line)
+		if err != nil {
+			return nil, err
+		}
+
+		m.rules = append(m.rules, r)
+	}
+
+	return m, nil
+}
+
+// LoadMatcherFile читает sPath (обычно — .fdignore внутри директории) и
+// разбирает его правила в Matcher. Отсутствие файла не является ошибкой —
+// в этом случае возвращается пустой Matcher.
+func LoadMatcherFile(sPath string) (*Matcher, error) {
+	f, err := os.Open(sPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Matcher{}, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewMatcher(lines)
+}
+
+// Merge возвращает новый Matcher, в котором после правил m идут правила
+// other. Порядок важен: как и в .gitignore, побеждает последнее подошедшее
+// правило, поэтому правила более вложенного .fdignore (other) могут
+// переопределить правила родительской директории (m).
+func (m *Matcher) Merge(other *Matcher) *Matcher {
+	if m == nil {
+		return other
+	}
+	if other == nil || len(other.rules) == 0 {
+		return m
+	}
+
+	merged := &Matcher{rules: make([]rule, 0, len(m.rules)+len(other.rules))}
+	merged.rules = append(merged.rules, m.rules...)
+	merged.rules = append(merged.rules, other.rules...)
+
+	return merged
+}
+
+// Match сообщает, нужно ли игнорировать relPath — путь от корня
+// сканирования, записанный через "/" независимо от ОС. Как и в .gitignore,
+// побеждает последнее подошедшее правило; отрицающее правило ("!") отменяет
+// игнорирование, установленное более ранним правилом.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+
+	ignored := false
+
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		if r.re.MatchString(relPath) {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
+
+// compileRule транслирует одну строку .gitignore-правила в regexp
+func compileRule(line string) (rule, error) {
+	negate := strings.HasPrefix(line, "!")
+	if negate {
+		line = line[1:]
+	}
+
+	dirOnly := strings.HasSuffix(line, "/")
+	line = strings.TrimSuffix(line, "/")
+
+	anchored := strings.HasPrefix(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	hasSlash := anchored || strings.Contains(line, "/")
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !hasSlash {
+		// Шаблон без "/" (кроме хвостового "/") совпадает с именем на любой
+		// глубине, как "*.log" в .gitignore
+		sb.WriteString("(.*/)?")
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			i++
+			if i+1 < len(runes) && runes[i+1] == '/' {
+				sb.WriteString("(.*/)?")
+				i++
+			} else {
+				sb.WriteString(".*")
+			}
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return rule{}, err
+	}
+
+	return rule{negate: negate, dirOnly: dirOnly, re: re}, nil
+}
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMatcherMatch проверяет транслируемую compileRule грамматику:
+// "*.log" совпадает на любой глубине, "**" — произвольное число директорий,
+// "/" в начале — привязку к корню, "/" в конце — только директории,
+// "!" — отрицание более раннего правила.
+func TestMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{name: "имя без слэша на любой глубине", rules: []string{"*.log"}, path: "a/b/c.log", isDir: false, ignored: true},
+		{name: "имя без слэша не трогает другое расширение", rules: []string{"*.log"}, path: "a/b/c.txt", isDir: false, ignored: false},
+		{name: "** в середине пути — ноль и более директорий", rules: []string{"a/**/c.log"}, path: "a/c.log", isDir: false, ignored: true},
+		{name: "** матчит несколько уровней директорий", rules: []string{"a/**/c.log"}, path: "a/b/d/c.log", isDir: false, ignored: true},
+		{name: "якорь / привязывает правило к корню", rules: []string{"/build"}, path: "sub/build", isDir: true, ignored: false},
+		{name: "якорь / совпадает в корне", rules: []string{"/build"}, path: "build", isDir: true, ignored: true},
+		{name: "хвостовой / действует только на директории", rules: []string{"build/"}, path: "build", isDir: false, ignored: false},
+		{name: "хвостовой / матчит директорию", rules: []string{"build/"}, path: "build", isDir: true, ignored: true},
+		{name: "более позднее правило побеждает отрицанием", rules: []string{"*.log", "!keep.log"}, path: "keep.log", isDir: false, ignored: false},
+		{name: "отрицание не воскрешает неподходящее под него правило", rules: []string{"*.log", "!keep.txt"}, path: "drop.log", isDir: false, ignored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.rules)
+			if err != nil {
+				t.Fatalf("ошибка NewMatcher(%v): %s", tt.rules, err)
+			}
+
+			if got := m.Match(tt.path, tt.isDir); got != tt.ignored {
+				t.Fatalf("Match(%q, isDir=%v) = %v, хотим %v", tt.path, tt.isDir, got, tt.ignored)
+			}
+		})
+	}
+}
+
+// TestMatcherMatchNilReceiver проверяет, что nil-Matcher (каталог без
+// .fdignore и без корневого matcher) ничего не игнорирует.
+func TestMatcherMatchNilReceiver(t *testing.T) {
+	var m *Matcher
+
+	if m.Match("anything", false) {
+		t.Fatal("nil-Matcher не должен ничего игнорировать")
+	}
+}
+
+// TestMatcherMerge проверяет, что правило вложенного .fdignore (other)
+// применяется после правил родителя и может переопределить его решение.
+func TestMatcherMerge(t *testing.T) {
+	parent, err := NewMatcher([]string{"*.log"})
+	if err != nil {
+		t.Fatalf("ошибка NewMatcher: %s", err)
+	}
+
+	child, err := NewMatcher([]string{"!keep.log"})
+	if err != nil {
+		t.Fatalf("ошибка NewMatcher: %s", err)
+	}
+
+	merged := parent.Merge(child)
+
+	if merged.Match("drop.log", false) != true {
+		t.Fatal("drop.log должен остаться проигнорированным")
+	}
+	if merged.Match("keep.log", false) != false {
+		t.Fatal("keep.log должен быть отменён правилом дочернего .fdignore")
+	}
+}
+
+// TestLoadMatcherFileMissing проверяет, что отсутствие .fdignore — не
+// ошибка, а пустой Matcher.
+func TestLoadMatcherFileMissing(t *testing.T) {
+	m, err := LoadMatcherFile(filepath.Join(t.TempDir(), "нет-такого-файла"))
+	if err != nil {
+		t.Fatalf("отсутствие файла не должно быть ошибкой: %s", err)
+	}
+	if m.Match("anything", false) {
+		t.Fatal("пустой Matcher не должен ничего игнорировать")
+	}
+}
+
+// TestLoadMatcherFileParsesComments проверяет, что пустые строки и строки,
+// начинающиеся с "#", пропускаются при разборе файла.
+func TestLoadMatcherFileParsesComments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FdignoreFileName)
+
+	content := "# комментарий\n\n*.tmp\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("ошибка записи %s: %s", path, err)
+	}
+
+	m, err := LoadMatcherFile(path)
+	if err != nil {
+		t.Fatalf("ошибка LoadMatcherFile: %s", err)
+	}
+
+	if !m.Match("a.tmp", false) {
+		t.Fatal("правило *.tmp должно было распарситься из файла")
+	}
+}
@@ -0,0 +1,59 @@
+package process
+
+import (
+	"io"
+	"os"
+
+	"github.com/zeebo/blake3"
+)
+
+// Размер префикса файла (в байтах), который читается на втором этапе
+// поиска дубликатов для предварительного отсева по хэшу начала файла
+const prefixHashSize = 4096
+
+// Algorithm задаёт алгоритм, по которому файлы-кандидаты признаются дубликатами
+type Algorithm uint8
+
+const (
+	// AlgorithmSizeOnly считает дубликатами файлы с одинаковым размером
+	// (старое поведение, самое быстрое и самое неточное)
+	AlgorithmSizeOnly Algorithm = iota
+	// AlgorithmSizePrefixHash дополнительно сверяет хэш первых prefixHashSize байт файла
+	AlgorithmSizePrefixHash
+	// AlgorithmFull дополнительно считает полный хэш файла и сверяет его целиком
+	AlgorithmFull
+)
+
+// hashPrefix вычисляет хэш первых prefixHashSize байт файла sPath.
+// Если файл короче prefixHashSize, хэшируется всё его содержимое.
+func hashPrefix(sPath string) ([]byte, error) {
+	f, err := os.Open(sPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := blake3.New()
+	if _, err := io.CopyN(h, f, prefixHashSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// hashFull вычисляет хэш всего содержимого файла sPath, читая его
+// потоково без загрузки целиком в память.
+func hashFull(sPath string) ([]byte, error) {
+	f, err := os.Open(sPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := blake3.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
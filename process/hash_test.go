@@ -0,0 +1,96 @@
+package process
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TestHashFull проверяет, что hashFull даёт одинаковый хэш для одинакового
+// содержимого и разный — для разного, даже если файлы одного размера.
+func TestHashFull(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, body []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			t.Fatalf("ошибка записи файла %s: %s", path, err)
+		}
+
+		return path
+	}
+
+	pathA := write("a.bin", []byte("hello"))
+	pathB := write("b.bin", []byte("world"))
+	pathC := write("c.bin", []byte("hello"))
+
+	hashA, err := hashFull(pathA)
+	if err != nil {
+		t.Fatalf("ошибка hashFull: %s", err)
+	}
+	hashB, err := hashFull(pathB)
+	if err != nil {
+		t.Fatalf("ошибка hashFull: %s", err)
+	}
+	hashC, err := hashFull(pathC)
+	if err != nil {
+		t.Fatalf("ошибка hashFull: %s", err)
+	}
+
+	if bytes.Equal(hashA, hashB) {
+		t.Fatal("хэши файлов разного содержимого (но одного размера) не должны совпадать")
+	}
+	if !bytes.Equal(hashA, hashC) {
+		t.Fatal("хэши файлов с одинаковым содержимым должны совпадать")
+	}
+}
+
+// TestDuplicateGroupFindFull проверяет сам смысл многоэтапного поиска: файлы
+// одного размера с разным именем, но одинаковым содержимым — дубликаты;
+// файл того же размера с другим содержимым — не дубликат, несмотря на
+// совпадение по размеру (на чём и спотыкается AlgorithmSizeOnly).
+func TestDuplicateGroupFindFull(t *testing.T) {
+	dir := t.TempDir()
+
+	mustWrite := func(name string, body []byte) FindDuplicate {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			t.Fatalf("ошибка записи файла %s: %s", path, err)
+		}
+
+		return FindDuplicate{DirName: dir, FileName: name, FileSize: int64(len(body))}
+	}
+
+	bodyA := bytes.Repeat([]byte("a"), 16)
+	bodyB := bytes.Repeat([]byte("b"), 16)
+
+	same := mustWrite("same.txt", bodyA)
+	copyOfSame := mustWrite("copy.txt", bodyA)
+	different := mustWrite("different.txt", bodyB)
+
+	hLog := log.WithFields(log.Fields{"test": "duplicate-group-find"})
+	options := OptionsNew(false, false, -1, AlgorithmFull, hLog)
+
+	groups := duplicateGroupFind(options, []FindDuplicate{same, copyOfSame, different})
+
+	if len(groups) != 1 {
+		t.Fatalf("ожидалась 1 группа дубликатов, получено %d: %+v", len(groups), groups)
+	}
+	if len(groups[0]) != 2 {
+		t.Fatalf("ожидалось 2 файла в группе дубликатов, получено %d", len(groups[0]))
+	}
+
+	names := map[string]bool{}
+	for _, fd := range groups[0] {
+		names[fd.FileName] = true
+	}
+	if !names["same.txt"] || !names["copy.txt"] {
+		t.Fatalf("в группу дубликатов должны были попасть same.txt и copy.txt, получено %+v", groups[0])
+	}
+	if names["different.txt"] {
+		t.Fatal("different.txt не должен считаться дубликатом, несмотря на совпадающий размер")
+	}
+}
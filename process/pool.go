@@ -0,0 +1,104 @@
+package process
+
+import "sync"
+
+// Pool — пул из фиксированного числа долгоживущих воркеров, разбирающих
+// задания из общей очереди (в отличие от прежней схемы "одна горутина на
+// поддиректорию", где число горутин росло вместе с глубиной дерева).
+//
+// Задание, выполняясь внутри воркера, само отправляет в пул новые задания
+// (каталог, обрабатываясь в одном воркере, кладёт в очередь свои
+// поддиректории) — поэтому очередь не может быть бесконечным
+// канал-буфером: если бы Submit был блокирующей отправкой в chan func()
+// фиксированной ёмкости, воркер, заполнивший очередь заданиями из одного
+// каталога с большим числом поддиректорий, завис бы в самом Submit — а ведь
+// читать из этой же очереди, кроме воркеров пула, больше некому, и ни один
+// из них эту отправку не разгрузит. Поэтому очередь — растущий список под
+// мьютексом с sync.Cond, и Submit никогда не блокируется.
+type Pool struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   []func()
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewPool запускает n воркеров, разбирающих задания из общей очереди.
+// queueCapacityHint — только подсказка для начальной ёмкости внутреннего
+// среза (не ограничивает число заданий, которые можно отправить через
+// Submit). n меньше 1 трактуется как 1.
+func NewPool(n int, queueCapacityHint int) *Pool {
+	if n < 1 {
+		n = 1
+	}
+	if queueCapacityHint < 0 {
+		queueCapacityHint = 0
+	}
+
+	p := &Pool{jobs: make([]func(), 0, queueCapacityHint)}
+	p.cond = sync.NewCond(&p.mu)
+
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer p.wg.Done()
+
+			for {
+				job, ok := p.next()
+				if !ok {
+					return
+				}
+
+				job()
+			}
+		}()
+	}
+
+	return p
+}
+
+// next забирает из очереди следующее задание, ожидая его появления, если
+// очередь пуста. Возвращает ok == false, когда пул закрыт и очередь опустела
+func (p *Pool) next() (func(), bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.jobs) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+
+	if len(p.jobs) == 0 {
+		return nil, false
+	}
+
+	job := p.jobs[0]
+	p.jobs = p.jobs[1:]
+
+	return job, true
+}
+
+// Submit кладёт job в очередь на выполнение одним из воркеров пула.
+// Не блокируется — это и можно, и нужно вызывать из задания, которое сейчас
+// выполняется воркером того же пула (см. комментарий к Pool). Вызывать
+// Submit после Close нельзя.
+func (p *Pool) Submit(job func()) {
+	p.mu.Lock()
+	p.jobs = append(p.jobs, job)
+	p.mu.Unlock()
+
+	p.cond.Signal()
+}
+
+// Close сообщает воркерам, что новых заданий не будет, и ждёт, пока они
+// доберут из очереди всё уже отправленное и завершатся. Вызывать только
+// тогда, когда все отправленные задания уже выполнены — обычно это
+// обеспечивается отдельным sync.WaitGroup, которым вызывающий код считает
+// незавершённые задания (Submit сам по себе не блокируется до выполнения job).
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.cond.Broadcast()
+	p.wg.Wait()
+}
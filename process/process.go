@@ -2,37 +2,77 @@
 // через указание основной директории. Пакет анализирует файлы внутри директорий
 // в нескольких потоках, и ищет дубликаты в них через специальную функцию анализатор.
 //
-//  func StartDuplicateFind(options Options, ch <-chan FindDuplicate, wg *sync.WaitGroup)
+//	func StartDuplicateFind(options *Options, ch <-chan FindDuplicate, wg *sync.WaitGroup)
 //
-// Найденные дубликаты файлов могут быть просто перечислены
-// пакетом или удалены. Удалять дубликаты можно с подтверждением пользователя
-// через комендную строку. Для уточнения способа работы с дубликатамив пакете
-// реализован тип-структура с описанием флагов. Эта структура требуется во всех
-// функциях пакета.
-//   Настройки поведения для процесса анализа дубликатов
-//   type Options struct {
-//      // true если требуется подтверждение перед удалением файла
-//      MustConfirmationDelete bool
-//      // true если требуется удалять файлы-дубликаты
-//      NeedRemoveDuplicate bool
-//    }
+// Поиск дубликатов — многоэтапный: сначала файлы группируются по размеру,
+// затем (в зависимости от Options.AlgorithmGet) по хэшу префикса файла и,
+// наконец, по полному хэшу содержимого, — так что дубликатами признаются
+// только действительно совпадающие файлы, а не просто файлы с одинаковым
+// именем и размером. Для больших файлов вместо полного хэширования можно
+// включить Options.BlockModeGet — тогда файлы делятся на блоки (см. пакет
+// process/blocks) и сравниваются поблочно, с ленивым вычислением строгого
+// хэша только для блоков с совпавшим слабым хэшем.
+//
+// Перед сравнением файлы и директории проходят через Options.MatcherGet
+// (gitignore-подобные правила, см. process/filter) и границы Options.MinSizeGet
+// /Options.MaxSizeGet/Options.CrossFilesystemsGet/Options.FollowSymlinksGet —
+// так отсеиваются системные каталоги, точки монтирования и симлинк-циклы.
 //
+// Обход директорий и подготовка найденных файлов выполняются двумя
+// отдельными пулами воркеров фиксированного размера (см. Pool) — dirPool и
+// filePool, вместо отдельной горутины на каждую поддиректорию. Очередь
+// заданий каждого пула ничем не ограничена (Pool.Submit не блокируется),
+// поскольку воркер, обходящий каталог, сам кладёт в dirPool задания на его
+// поддиректории — если бы Submit блокировался при заполнении очереди, такой
+// воркер мог бы зависнуть в собственной отправке. Backpressure даёт только
+// буферизованный канал chanDupl — на нём воркеры filePool (а не друг друга)
+// ждут единственного потребителя, StartDuplicateFind. Ход сканирования можно
+// наблюдать через Options.ProgressTrackerGet (см. process/progress).
 //
+// Найденные дубликаты файлов обрабатываются операцией Options.OpGet (см.
+// Deduper): их можно просто перечислить, удалить, заменить жёсткой или
+// символьной ссылкой на оригинал, либо заменить CoW-клоном (reflink).
+// Обрабатывать дубликаты можно с подтверждением пользователя через
+// командную строку; при большом числе найденных дубликатов подтверждение
+// автоматически заменяется формированием shell-скрипта (Options.DryRunGet).
+// Для уточнения способа работы с дубликатами в пакете реализован
+// тип-структура с описанием флагов. Эта структура требуется во всех
+// функциях пакета.
+//
+//	Настройки поведения для процесса анализа дубликатов
+//	type Options struct {
+//	   // true если требуется подтверждение перед удалением файла
+//	   MustConfirmationDelete bool
+//	   // true если требуется удалять файлы-дубликаты
+//	   NeedRemoveDuplicate bool
+//	 }
 package process
 
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"sync"
 
 	"github.com/sirupsen/logrus"
+
+	"FindDuplicate/process/blocks"
+	"FindDuplicate/process/filter"
+	"FindDuplicate/process/progress"
 )
 
 type FindDuplicate struct {
 	DirName  string
 	FileName string
 	FileSize int64
+	// Digest заполняется на этапах хэширования (см. Options.AlgorithmGet) и
+	// пуст для сигнальных записей каталога и для AlgorithmSizeOnly
+	Digest []byte
+	// Blocks заполняется при включённом Options.BlockModeGet взамен Digest
+	// (см. blocks.Split) и используется для поблочного сравнения больших файлов
+	Blocks   []blocks.BlockInfo
 	workerId uint16
 }
 
@@ -47,8 +87,42 @@ type Options struct {
 	// Максимальное число потоков, анализируюбщие директории
 	// -1 - бесконечное число потоков
 	maxCountThread int16
-	// Текущее число работабщих потоков, анализирующие директории
-	currentThreadCount int16
+	// Алгоритм, по которому файлы-кандидаты признаются дубликатами
+	algorithm Algorithm
+	// true если вместо хэш-пайплайна (algorithm) нужно сравнивать файлы
+	// поблочно через process/blocks — дешевле для больших файлов
+	blockMode bool
+	// Размер блока для BlockMode, в байтах
+	blockSize int
+	// Операция, применяемая к найденным дубликатам (см. Deduper)
+	op Op
+	// true если вместо применения op нужно вывести эквивалентный shell-скрипт
+	dryRun bool
+	// Куда пишется shell-скрипт в режиме dryRun
+	dedupeWriter io.Writer
+	// Формирователь итогового отчёта о найденных дубликатах (см. Reporter).
+	// nil, если отчёт формировать не нужно
+	reporter Reporter
+	// Корневые директории сканирования, попадают в ReportHeader.ScanRoots
+	scanRoots []string
+	// Корневой Matcher (см. process/filter), объединяемый с .fdignore
+	// каждой вложенной директории. nil означает отсутствие фильтрации
+	matcher *filter.Matcher
+	// Файлы меньше minSize в сравнение не попадают (0 - без ограничения снизу)
+	minSize int64
+	// Файлы больше maxSize в сравнение не попадают (0 - без ограничения сверху)
+	maxSize int64
+	// true, если нужно заходить внутрь симлинков на директории
+	followSymlinks bool
+	// true, если нужно спускаться в директории на других файловых системах
+	// (точки монтирования, bind-mount'ы)
+	crossFilesystems bool
+	// Набор уже посещённых inode (dev, ino), используется для защиты от
+	// зацикливания на симлинках при followSymlinks == true
+	visitedInodes map[[2]uint64]struct{}
+	// Наблюдатель за ходом сканирования (см. process/progress). nil означает,
+	// что прогресс нигде не отражается
+	progressTracker progress.Tracker
 	// Защита доступа к данным структуры из горутин
 	mux sync.RWMutex
 	// логирование на прямую пока без декораторов
@@ -61,16 +135,34 @@ func OptionsNewDefault() *Options {
 		mustConfirmationDelete: true,
 		needRemoveDuplicate:    false,
 		maxCountThread:         -1,
+		algorithm:              AlgorithmFull,
+		blockMode:              false,
+		blockSize:              blocks.DefaultBlockSize,
+		op:                     OpReport,
+		dryRun:                 false,
+		dedupeWriter:           os.Stdout,
+		followSymlinks:         false,
+		crossFilesystems:       false,
+		visitedInodes:          map[[2]uint64]struct{}{},
 		mux:                    sync.RWMutex{},
 		hLog:                   nil,
 	}
 }
 
-func OptionsNew(mustConfirmationDelete bool, needRemoveDuplicate bool, maxCountThread int16, hLog *logrus.Entry) *Options {
+func OptionsNew(mustConfirmationDelete bool, needRemoveDuplicate bool, maxCountThread int16, algorithm Algorithm, hLog *logrus.Entry) *Options {
 	return &Options{
 		mustConfirmationDelete: mustConfirmationDelete,
 		needRemoveDuplicate:    needRemoveDuplicate,
 		maxCountThread:         maxCountThread,
+		algorithm:              algorithm,
+		blockMode:              false,
+		blockSize:              blocks.DefaultBlockSize,
+		op:                     OpReport,
+		dryRun:                 false,
+		dedupeWriter:           os.Stdout,
+		followSymlinks:         false,
+		crossFilesystems:       false,
+		visitedInodes:          map[[2]uint64]struct{}{},
 		mux:                    sync.RWMutex{},
 		hLog:                   hLog,
 	}
@@ -124,55 +216,264 @@ func (o *Options) MaxCountThreadSet(val int16) {
 	o.maxCountThread = val
 }
 
-// Геттер для currentThreadCount
-func (o *Options) CurrentThreadCountGet() int16 {
+// Геттер для algorithm
+func (o *Options) AlgorithmGet() Algorithm {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.algorithm
+}
+
+// Сеттер для algorithm
+func (o *Options) AlgorithmSet(val Algorithm) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.algorithm = val
+}
+
+// Геттер для blockMode
+func (o *Options) BlockModeGet() bool {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.blockMode
+}
+
+// Сеттер для blockMode
+func (o *Options) BlockModeSet(val bool) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.blockMode = val
+}
+
+// Геттер для blockSize
+func (o *Options) BlockSizeGet() int {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.blockSize
+}
+
+// Сеттер для blockSize
+func (o *Options) BlockSizeSet(val int) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.blockSize = val
+}
+
+// Геттер для op
+func (o *Options) OpGet() Op {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.op
+}
+
+// Сеттер для op
+func (o *Options) OpSet(val Op) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.op = val
+}
+
+// Геттер для dryRun
+func (o *Options) DryRunGet() bool {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.dryRun
+}
+
+// Сеттер для dryRun
+func (o *Options) DryRunSet(val bool) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.dryRun = val
+}
+
+// Геттер для dedupeWriter
+func (o *Options) DedupeWriterGet() io.Writer {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.dedupeWriter
+}
+
+// Сеттер для dedupeWriter
+func (o *Options) DedupeWriterSet(val io.Writer) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.dedupeWriter = val
+}
+
+// Геттер для reporter
+func (o *Options) ReporterGet() Reporter {
 	o.mux.RLock()
 	defer o.mux.RUnlock()
 
-	return o.currentThreadCount
+	return o.reporter
 }
 
-// Сеттер для currentThreadCount
-func (o *Options) CurrentThreadCountSet(val int16) {
+// Сеттер для reporter
+func (o *Options) ReporterSet(val Reporter) {
 	o.mux.Lock()
 	defer o.mux.Unlock()
 
-	o.currentThreadCount = val
+	o.reporter = val
 }
 
-// Если возможно добавить поток-воркер (currentThreadCount < MaxCountThread),
-// то добавляется новая горутина и функция возвращает true, иначе возвращает false
-func (o *Options) AddWorker() bool {
+// Геттер для scanRoots
+func (o *Options) ScanRootsGet() []string {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.scanRoots
+}
+
+// Сеттер для scanRoots
+func (o *Options) ScanRootsSet(val []string) {
 	o.mux.Lock()
 	defer o.mux.Unlock()
 
-	if o.maxCountThread == -1 || o.currentThreadCount < o.maxCountThread {
-		o.currentThreadCount++
-		return true
+	o.scanRoots = val
+}
+
+// Геттер для matcher
+func (o *Options) MatcherGet() *filter.Matcher {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.matcher
+}
+
+// Сеттер для matcher
+func (o *Options) MatcherSet(val *filter.Matcher) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.matcher = val
+}
+
+// Геттер для minSize
+func (o *Options) MinSizeGet() int64 {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.minSize
+}
+
+// Сеттер для minSize
+func (o *Options) MinSizeSet(val int64) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.minSize = val
+}
+
+// Геттер для maxSize
+func (o *Options) MaxSizeGet() int64 {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.maxSize
+}
+
+// Сеттер для maxSize
+func (o *Options) MaxSizeSet(val int64) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.maxSize = val
+}
+
+// Геттер для followSymlinks
+func (o *Options) FollowSymlinksGet() bool {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.followSymlinks
+}
+
+// Сеттер для followSymlinks
+func (o *Options) FollowSymlinksSet(val bool) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.followSymlinks = val
+}
+
+// Геттер для crossFilesystems
+func (o *Options) CrossFilesystemsGet() bool {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.crossFilesystems
+}
+
+// Сеттер для crossFilesystems
+func (o *Options) CrossFilesystemsSet(val bool) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.crossFilesystems = val
+}
+
+// markInodeVisited отмечает inode (dev, ino) посещённым и возвращает true,
+// если он посещается впервые. Повторное посещение того же inode означает
+// цикл через симлинки и позволяет StartContentChanges прервать обход.
+func (o *Options) markInodeVisited(dev uint64, ino uint64) bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	key := [2]uint64{dev, ino}
+	if _, ok := o.visitedInodes[key]; ok {
+		return false
 	}
 
-	return false
+	o.visitedInodes[key] = struct{}{}
+
+	return true
 }
 
-// Уменьшение числа текущих потоков
-func (o *Options) RemoveWorker() {
+// Геттер для progressTracker
+func (o *Options) ProgressTrackerGet() progress.Tracker {
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+
+	return o.progressTracker
+}
+
+// Сеттер для progressTracker
+func (o *Options) ProgressTrackerSet(val progress.Tracker) {
 	o.mux.Lock()
 	defer o.mux.Unlock()
 
-	o.currentThreadCount--
+	o.progressTracker = val
 }
 
-// Анализ файлов из канала на предмет дубликата
-// в параметре options передаются настройки поведения анализатора
-// в канкле сh передаётся структура с указанием директории, которая сейчас обрабатывается,
-// если в options.MustConfirmationDelete == true или указывается название файла,
+// Анализ файлов из канала на предмет дубликата.
+// В параметре options передаются настройки поведения анализатора.
+// В канале ch передаётся структура с указанием директории, которая сейчас обрабатывается,
+// если в options.MustConfirmationDelete == true, или указывается название файла,
 // совместно с директорией для дальнейшего анализа на дубликаты.
+//
+// Сначала все файлы из канала группируются по размеру (фаза 1), после чего
+// для каждой группы из >= 2 файлов выполняется сверка по алгоритму
+// options.AlgorithmGet() (фазы 2 и 3, см. duplicateGroupFind). Файлы нулевого
+// размера в сравнение не попадают и под удаление не подпадают.
 func StartDuplicateFind(options *Options, ch <-chan FindDuplicate, wg *sync.WaitGroup) {
 	options.hLog.Info("Старт поиска дубликатов...")
 	defer wg.Done()
 	defer options.hLog.Info("Поиск дубликатов прекращён")
 
-	mapFiles := map[string]struct{}{}
+	// Фаза 1: группировка кандидатов по точному размеру файла
+	bySize := map[int64][]FindDuplicate{}
 
 	for fd := range ch {
 		// Если требуется прерывания на согласие пользователя,
@@ -184,60 +485,257 @@ func StartDuplicateFind(options *Options, ch <-chan FindDuplicate, wg *sync.Wait
 
 		options.hLog.Debugf("Найден файл: %s\n", fd.DirName+"/"+fd.FileName)
 
-		// Если файл fd.FileName + fd.Size уже есть в списке, то это дубликат
-		sMapKey := fmt.Sprintf("%s_%d", fd.FileName, fd.FileSize)
-		_, ok := mapFiles[sMapKey]
-		// Почему-то запись if _, ok := mapFiles[fd.FileName] ругается на знак подчёркивания
-		if ok {
-			options.hLog.Debugf("Найден дубликат. Файл: %s (size: %d)\n", fd.DirName+"/"+fd.FileName, fd.FileSize)
-
-			// Ожидание ввода пользователя
-			if options.MustConfirmationDeleteGet() && options.NeedRemoveDuplicateGet() {
-				options.hLog.Debugf("Удалить файл %s (size: %d)? (y, n)", fd.DirName+"/"+fd.FileName, fd.FileSize)
-				fmt.Printf("Удалить файл %s (size: %d)? (y, n)", fd.DirName+"/"+fd.FileName, fd.FileSize)
-
-				scanner := bufio.NewScanner(os.Stdin)
-				fl := true
-				for fl {
-					for scanner.Scan() {
-						txt := scanner.Text()
-						switch txt {
-						case "y":
-							{
-								options.hLog.Debugf("\n Файл %s удалён!\n", fd.DirName+"/"+fd.FileName)
-								fmt.Printf("\n Файл %s удалён!\n", fd.DirName+"/"+fd.FileName)
-								fl = false
-							}
-						case "n":
-							{
-								options.hLog.Debug("Пропуск\n")
-								fmt.Printf("Пропуск\n")
-								fl = false
-							}
-						default:
-							options.hLog.Debug("Неверный ввод. Повторите (y/n):")
-							fmt.Print("Неверный ввод. Повторите (y/n):")
-						}
-
-						break
-					}
-				}
-			} else {
-				options.hLog.Debugf("Файл %s удалён!\n", fd.DirName+"/"+fd.FileName)
-				fmt.Printf("Файл %s удалён!\n", fd.DirName+"/"+fd.FileName)
-			}
+		// Файлы нулевого размера исключаются из поиска дубликатов по умолчанию
+		if fd.FileSize == 0 {
+			continue
+		}
+
+		bySize[fd.FileSize] = append(bySize[fd.FileSize], fd)
+	}
+
+	allDupGroups := make([][]FindDuplicate, 0)
+	for _, sizeGroup := range bySize {
+		if len(sizeGroup) < 2 {
+			continue
+		}
+
+		if options.BlockModeGet() {
+			allDupGroups = append(allDupGroups, blockGroupFind(options, sizeGroup)...)
 		} else {
-			mapFiles[sMapKey] = struct{}{}
+			allDupGroups = append(allDupGroups, duplicateGroupFind(options, sizeGroup)...)
+		}
+	}
+
+	totalDuplicates := 0
+	for _, dupGroup := range allDupGroups {
+		totalDuplicates += len(dupGroup) - 1
+	}
+
+	// Если найдено слишком много дубликатов, запрашивать подтверждение на
+	// каждый файл по отдельности непрактично — вместо этого формируется
+	// shell-скрипт (как log_script/run_script у fclones), который
+	// пользователь может просмотреть и выполнить сам
+	dryRun := options.DryRunGet()
+	needsConfirmation := options.MustConfirmationDeleteGet() && options.NeedRemoveDuplicateGet() && options.OpGet() != OpReport
+	if !dryRun && needsConfirmation && totalDuplicates > manyDuplicatesThreshold {
+		options.hLog.Infof("Найдено %d дубликатов, формируется shell-скрипт вместо запроса подтверждения на каждый файл", totalDuplicates)
+		dryRun = true
+	}
+
+	deduper := NewDeduper(options.OpGet(), dryRun, options.DedupeWriterGet())
+
+	for _, dupGroup := range allDupGroups {
+		reportDuplicateGroup(options, deduper, dryRun, dupGroup)
+	}
+
+	if reporter := options.ReporterGet(); reporter != nil {
+		entries := newReportEntries(options.hLog, allDupGroups)
+		header := newReportHeader(options.ScanRootsGet(), entries)
+
+		if err := reporter.WriteReport(header, entries); err != nil {
+			options.hLog.WithFields(logrus.Fields{"StartDuplicateFind": ""}).Errorf("ошибка формирования отчёта: %s", err)
 		}
 	}
 }
 
-// Обход дерева директорий с созданием для каждой поддиректории,
-// включая заданную потока для отслеживания файлов-дубликатов
+// manyDuplicatesThreshold — если найденных дубликатов больше этого числа,
+// StartDuplicateFind не спрашивает подтверждение на каждый файл, а переходит
+// в режим формирования shell-скрипта (см. Options.DryRunGet)
+const manyDuplicatesThreshold = 50
+
+// duplicateGroupFind выполняет фазы 2 и 3 многоэтапного поиска дубликатов
+// внутри одной группы файлов-кандидатов с одинаковым размером:
+//
+//	фаза 2 — группировка по хэшу префикса файла (hashPrefix);
+//	фаза 3 — группировка по полному хэшу файла (hashFull).
+//
+// Глубина прохода определяется options.AlgorithmGet(). Обе фазы хэшируют
+// файлы группы не более чем в options.MaxCountThreadGet() потоков одновременно
+// (см. runBounded). Возвращаются итоговые подгруппы настоящих дубликатов
+// (каждая из >= 2 файлов), с заполненным полем Digest; для AlgorithmSizeOnly
+// группа по размеру возвращается как есть, без хэширования и без Digest.
+func duplicateGroupFind(options *Options, sizeGroup []FindDuplicate) [][]FindDuplicate {
+	if options.AlgorithmGet() == AlgorithmSizeOnly {
+		return [][]FindDuplicate{sizeGroup}
+	}
+
+	prefixGroups := groupByHash(options, sizeGroup, hashPrefix)
+
+	if options.AlgorithmGet() == AlgorithmSizePrefixHash {
+		return prefixGroups
+	}
+
+	fullGroups := make([][]FindDuplicate, 0, len(prefixGroups))
+	for _, prefixGroup := range prefixGroups {
+		fullGroups = append(fullGroups, groupByHash(options, prefixGroup, hashFull)...)
+	}
+
+	return fullGroups
+}
+
+// groupByHash вычисляет хэш каждого файла группы функцией hashFn и разбивает
+// группу на подгруппы файлов с совпадающим хэшем. Подгруппы, в которые попал
+// только один файл, отбрасываются — среди них дубликатов нет.
+func groupByHash(options *Options, group []FindDuplicate, hashFn func(string) ([]byte, error)) [][]FindDuplicate {
+	jobs := make([]func(), len(group))
+	for i := range group {
+		i := i
+		jobs[i] = func() {
+			sFilePath := group[i].DirName + "/" + group[i].FileName
+
+			digest, err := hashFn(sFilePath)
+			if err != nil {
+				options.hLog.WithFields(logrus.Fields{"groupByHash": ""}).Errorf("ошибка хэширования файла %s: %s", sFilePath, err)
+				group[i].Digest = nil
+				return
+			}
+
+			group[i].Digest = digest
+
+			if tracker := options.ProgressTrackerGet(); tracker != nil {
+				tracker.BytesHashed(group[i].FileSize)
+			}
+		}
+	}
+
+	runBounded(options.MaxCountThreadGet(), jobs)
+
+	byHash := map[string][]FindDuplicate{}
+	for _, fd := range group {
+		// Файл, который не удалось прохэшировать, из дальнейшего сравнения исключается
+		if fd.Digest == nil {
+			continue
+		}
+
+		byHash[string(fd.Digest)] = append(byHash[string(fd.Digest)], fd)
+	}
+
+	result := make([][]FindDuplicate, 0, len(byHash))
+	for _, hashGroup := range byHash {
+		if len(hashGroup) < 2 {
+			continue
+		}
+
+		result = append(result, hashGroup)
+	}
+
+	return result
+}
+
+// runBounded запускает jobs параллельно, но не более maxThreads одновременно.
+// maxThreads == -1 означает отсутствие ограничения.
+func runBounded(maxThreads int16, jobs []func()) {
+	limit := len(jobs)
+	if maxThreads > 0 && int(maxThreads) < limit {
+		limit = int(maxThreads)
+	}
+
+	sem := make(chan struct{}, limit)
+	jobsWg := sync.WaitGroup{}
+
+	for _, job := range jobs {
+		job := job
+
+		jobsWg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer jobsWg.Done()
+			defer func() { <-sem }()
+
+			job()
+		}()
+	}
+
+	jobsWg.Wait()
+}
+
+// reportDuplicateGroup считает первый файл группы оригиналом, а остальные —
+// его дубликатами, и применяет к каждому дубликату deduper (с подтверждением
+// пользователя, если это требуется настройками и skipConfirmation == false).
+func reportDuplicateGroup(options *Options, deduper Deduper, skipConfirmation bool, dupGroup []FindDuplicate) {
+	original := dupGroup[0]
+
+	for _, fd := range dupGroup[1:] {
+		sFilePath := fd.DirName + "/" + fd.FileName
+
+		options.hLog.Debugf("Найден дубликат. Файл: %s (size: %d), оригинал: %s\n",
+			sFilePath, fd.FileSize, original.DirName+"/"+original.FileName)
+
+		// Операция OpReport ничего не меняет на диске, поэтому подтверждение
+		// для неё не требуется; остальные операции (remove/hardlink/symlink/
+		// reflink) меняют файлы на диске и применяются, только если это явно
+		// запрошено (Options.NeedRemoveDuplicateGet, флаг -r), а подтверждение
+		// запрашивается, если это дополнительно предписано настройками
+		apply := options.OpGet() == OpReport
+		if !apply && options.NeedRemoveDuplicateGet() {
+			apply = !options.MustConfirmationDeleteGet() || skipConfirmation || confirmDuplicate(options, sFilePath, fd.FileSize)
+		}
+
+		if !apply {
+			options.hLog.Debug("Пропуск\n")
+			fmt.Printf("Пропуск\n")
+			continue
+		}
+
+		if err := deduper.Dedupe(original, fd); err != nil {
+			options.hLog.WithFields(logrus.Fields{"reportDuplicateGroup": ""}).Errorf("ошибка обработки дубликата %s: %s", sFilePath, err)
+			continue
+		}
+
+		options.hLog.Debugf("Файл %s обработан (op: %s)\n", sFilePath, options.OpGet())
+		fmt.Printf("Файл %s обработан (op: %s)\n", sFilePath, options.OpGet())
+	}
+}
+
+// confirmDuplicate запрашивает у пользователя в командной строке
+// подтверждение на обработку дубликата sFilePath
+func confirmDuplicate(options *Options, sFilePath string, size int64) bool {
+	options.hLog.Debugf("Обработать файл %s (size: %d)? (y, n)", sFilePath, size)
+	fmt.Printf("Обработать файл %s (size: %d)? (y, n)", sFilePath, size)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "y":
+			return true
+		case "n":
+			return false
+		default:
+			options.hLog.Debug("Неверный ввод. Повторите (y/n):")
+			fmt.Print("Неверный ввод. Повторите (y/n):")
+		}
+	}
+
+	return false
+}
+
+// dirWorkerPoolSize — число долгоживущих воркеров в пуле, обходящем
+// директории (см. Pool), по умолчанию равно числу ядер машины
+func dirWorkerPoolSize() int {
+	return runtime.NumCPU()
+}
+
+// Обход дерева директорий пулом воркеров фиксированного размера, включая
+// заданную директорию, для отслеживания файлов-дубликатов.
+//
+// Вместо прежней схемы "одна горутина на поддиректорию" с ручным счётчиком
+// currentThreadCount directory-job'ы разбираются Pool'ом из
+// dirWorkerPoolSize() воркеров, а found-файлы — отдельным Pool'ом такого же
+// размера (hashing pool), что отделяет обход директорий от подготовки
+// файлов к сравнению. Очереди обоих пулов ничем не ограничены (см. Pool) —
+// backpressure даёт только буферизованный канал chanDupl (N*16), на котором
+// воркеры filePool ждут единственного потребителя, StartDuplicateFind.
 func StartWatch(options *Options, fDir *os.File, wg *sync.WaitGroup) error {
-	// Запуск слежения за дубликатами в каталогах
+	options.ScanRootsSet([]string{fDir.Name()})
+
+	poolSize := dirWorkerPoolSize()
+	chanDuplCapacity := poolSize * 16
 
-	chanDupl := make(ChanFindDuplicate)
+	dirPool := NewPool(poolSize, chanDuplCapacity)
+	filePool := NewPool(poolSize, chanDuplCapacity)
+
+	chanDupl := make(ChanFindDuplicate, chanDuplCapacity)
 	wgDupl := sync.WaitGroup{}
 
 	wgDupl.Add(1)
@@ -245,20 +743,27 @@ func StartWatch(options *Options, fDir *os.File, wg *sync.WaitGroup) error {
 
 	wg.Add(1)
 	go func() {
-		// Первый поток
-		options.CurrentThreadCountSet(1)
-		err := StartContentChanges(options, fDir, wg, &chanDupl, 1)
+		defer wg.Done()
+
+		var rootDev uint64
+		if rootSt, err := os.Stat(fDir.Name()); err == nil {
+			rootDev, _, _ = statDevIno(rootSt)
+		}
+
+		err := StartContentChanges(options, fDir, wg, &chanDupl, 1, options.MatcherGet(), rootDev, dirPool, filePool)
 		if err != nil {
 			options.hLog.Fatalf("Ошибка запуска первого потока (%v)", err)
 		}
-
-		defer wg.Done()
 	}()
 
-	// Ожидание закрытия всех воркеров по поиску содержимого директорий
+	// Ожидание завершения обхода всех директорий и подготовки всех файлов
 	wg.Wait()
 
-	// Закрыте канала для прекращения работы потока по поиску дубликатов
+	// Оба пула больше не получат новых заданий — можно дождаться воркеров
+	dirPool.Close()
+	filePool.Close()
+
+	// Закрытие канала для прекращения работы потока по поиску дубликатов
 	close(chanDupl)
 	// Ожидание корректного закрытия потока анализа списка файлов на дубликаты
 	wgDupl.Wait()
@@ -266,8 +771,17 @@ func StartWatch(options *Options, fDir *os.File, wg *sync.WaitGroup) error {
 	return nil
 }
 
-// Запуск потока для отслеживания изменений в директории
-func StartContentChanges(options *Options, sDir *os.File, wg *sync.WaitGroup, signalChan *ChanFindDuplicate, idWorker uint16) error {
+// Запуск обхода содержимого директории. matcher — уже объединённый с
+// .fdignore всех директорий-предков Matcher (см. process/filter); parentDev —
+// номер устройства директории sDir, нужен для проверки
+// Options.CrossFilesystemsGet при спуске в поддиректории.
+//
+// Поддиректории передаются в dirPool как отдельные задания (вместо
+// собственной горутины на каждую), а найденные файлы — в filePool, который
+// готовит их к сравнению (в BlockMode — делит на блоки) и отправляет в
+// signalChan. wg считает задания, незавершённые в обоих пулах, и закрывается
+// вызывающей стороной (см. StartWatch) только после того, как он опустеет.
+func StartContentChanges(options *Options, sDir *os.File, wg *sync.WaitGroup, signalChan *ChanFindDuplicate, idWorker uint16, matcher *filter.Matcher, parentDev uint64, dirPool *Pool, filePool *Pool) error {
 	// Отправка сообщения "Обработка каталога" в поток анализа файлов
 	// если требуется подтверждение от пользователя
 	if options.MustConfirmationDeleteGet() {
@@ -277,6 +791,14 @@ func StartContentChanges(options *Options, sDir *os.File, wg *sync.WaitGroup, si
 		fmt.Printf("Обработка каталога (workerId:%d): %s", idWorker, sDir.Name())
 	}
 
+	matcher, err := loadDirMatcher(matcher, sDir.Name())
+	if err != nil {
+		e := fmt.Errorf("ошибка чтения %s в каталоге %s: %s", filter.FdignoreFileName, sDir.Name(), err)
+		options.hLog.WithFields(logrus.Fields{"StartContentChanges": ""}).Error(e)
+
+		return e
+	}
+
 	fileNames, err := sDir.Readdirnames(-1)
 	if err != nil {
 		e := fmt.Errorf("ошибка чтения каталога %s: %s", sDir.Name(), err)
@@ -287,7 +809,9 @@ func StartContentChanges(options *Options, sDir *os.File, wg *sync.WaitGroup, si
 
 	// Анализируем содержимое директории (файл и директории)
 	for _, s := range fileNames {
-		st, err := os.Stat(sDir.Name() + "/" + s)
+		sFullPath := sDir.Name() + "/" + s
+
+		lst, err := os.Lstat(sFullPath)
 		if err != nil {
 			e := fmt.Errorf("ошибка получения информации о файле в каталоге %s: %s", sDir.Name(), err)
 			options.hLog.WithFields(logrus.Fields{"StartContentChanges": ""}).Error(e)
@@ -295,33 +819,95 @@ func StartContentChanges(options *Options, sDir *os.File, wg *sync.WaitGroup, si
 			return e
 		}
 
+		isSymlink := lst.Mode()&os.ModeSymlink != 0
+		if isSymlink && !options.FollowSymlinksGet() {
+			continue
+		}
+
+		st := lst
+		if isSymlink {
+			// Переходим по симлинку, только чтобы узнать реальный тип файла
+			st, err = os.Stat(sFullPath)
+			if err != nil {
+				options.hLog.Debugf("битый симлинк %s: %s", sFullPath, err)
+				continue
+			}
+
+			// Защита от зацикливания: если на этот inode уже заходили через
+			// другой симлинк (или он сам себе предок), пропускаем его
+			if dev, ino, ok := statDevIno(st); ok && !options.markInodeVisited(dev, ino) {
+				options.hLog.Debugf("пропуск симлинка %s: обнаружен цикл", sFullPath)
+				continue
+			}
+		}
+
+		if matcher.Match(relPathFromRoots(options.ScanRootsGet(), sFullPath), st.IsDir()) {
+			continue
+		}
+
 		// Для каждого нового каталога запускается свой поток обработки
 		if st.IsDir() {
-			sCatalogName := sDir.Name() + "/" + st.Name()
+			dirDev, _, ok := statDevIno(st)
+			if ok && !options.CrossFilesystemsGet() && parentDev != 0 && dirDev != parentDev {
+				options.hLog.Debugf("пропуск каталога %s: другая файловая система", sFullPath)
+				continue
+			}
 
-			f, err := os.Open(sCatalogName)
+			f, err := os.Open(sFullPath)
 			if err != nil {
-				e := fmt.Errorf("ошибка чтения каталога %s: %s", sCatalogName, err)
+				e := fmt.Errorf("ошибка чтения каталога %s: %s", sFullPath, err)
 				options.hLog.WithFields(logrus.Fields{"StartContentChanges": ""}).Error(e)
 
 				return e
 			}
 
-			// Если можно запустить воркер для анализа директории
-			if options.AddWorker() {
-				wg.Add(1)
-				go func() {
-					StartContentChanges(options, f, wg, signalChan, idWorker+1)
-
-					defer wg.Done()
-					defer options.RemoveWorker()
-				}()
-			} else {
-				StartContentChanges(options, f, wg, signalChan, idWorker)
-			}
+			// Обход поддиректории — задание для dirPool, а не отдельная
+			// горутина: число одновременно выполняющихся обходов ограничено
+			// размером пула, а не растёт вместе с глубиной дерева
+			wg.Add(1)
+			dirPool.Submit(func() {
+				defer wg.Done()
+
+				if err := StartContentChanges(options, f, wg, signalChan, idWorker+1, matcher, dirDev, dirPool, filePool); err != nil {
+					options.hLog.WithFields(logrus.Fields{"StartContentChanges": ""}).Errorf("ошибка обхода каталога %s: %s", f.Name(), err)
+				}
+			})
 		} else {
-			// Отправка найденного файла в канал для его дальнейшего анализа
-			*signalChan <- FindDuplicate{DirName: sDir.Name(), FileName: st.Name(), FileSize: st.Size()}
+			if st.Size() < options.MinSizeGet() {
+				continue
+			}
+			if maxSize := options.MaxSizeGet(); maxSize > 0 && st.Size() > maxSize {
+				continue
+			}
+
+			if tracker := options.ProgressTrackerGet(); tracker != nil {
+				tracker.FileScanned()
+			}
+
+			fd := FindDuplicate{DirName: sDir.Name(), FileName: st.Name(), FileSize: st.Size()}
+
+			// Подготовка файла (в BlockMode — блочное разбиение со слабыми
+			// хэшами) и отправка в канал для дальнейшего анализа — задание
+			// для filePool, отдельного от dirPool, обходящего директории
+			wg.Add(1)
+			filePool.Submit(func() {
+				defer wg.Done()
+
+				// В режиме BlockMode воркер сам делит файл на блоки и сразу
+				// считает слабые хэши, чтобы это не делать повторно в потоке
+				// поиска дубликатов
+				if options.BlockModeGet() && fd.FileSize > 0 {
+					blockList, err := blocks.Split(sFullPath, options.BlockSizeGet())
+					if err != nil {
+						e := fmt.Errorf("ошибка блочного разбиения файла %s: %s", sFullPath, err)
+						options.hLog.WithFields(logrus.Fields{"StartContentChanges": ""}).Error(e)
+					} else {
+						fd.Blocks = blockList
+					}
+				}
+
+				*signalChan <- fd
+			})
 		}
 	}
 
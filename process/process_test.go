@@ -0,0 +1,228 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BenchmarkPool измеряет пропускную способность Pool на заведомо дешёвых
+// заданиях — помогает заметить регрессию в самой диспетчеризации заданий,
+// отдельно от стоимости обхода файловой системы или хэширования.
+func BenchmarkPool(b *testing.B) {
+	p := NewPool(dirWorkerPoolSize(), dirWorkerPoolSize()*16)
+	defer p.Close()
+
+	var wg sync.WaitGroup
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		p.Submit(func() {
+			defer wg.Done()
+		})
+	}
+	wg.Wait()
+}
+
+// stressTreeDirs/stressTreeFilesPerDir задают размер синтетического дерева
+// для TestStartWatchStressSyntheticTree: dirs*filesPerDir = 100_000 файлов.
+const (
+	stressTreeDirs          = 100
+	stressTreeFilesPerDir   = 1000
+	stressTreeDuplicateMod  = 10 // каждый stressTreeDuplicateMod-й файл — дубликат
+	stressTreeDuplicateBody = "duplicate-content"
+)
+
+// buildSyntheticTree создаёт в каталоге root dirs поддиректорий по
+// filesPerDir файлов в каждой, возвращая общее число созданных файлов.
+// Каждый duplicateMod-й файл получает одинаковое содержимое
+// stressTreeDuplicateBody, остальные — уникальное по имени содержимое.
+func buildSyntheticTree(tb testing.TB, root string, dirs, filesPerDir, duplicateMod int) int {
+	tb.Helper()
+
+	count := 0
+	for d := 0; d < dirs; d++ {
+		dirPath := filepath.Join(root, fmt.Sprintf("dir%d", d))
+		if err := os.MkdirAll(dirPath, 0o755); err != nil {
+			tb.Fatalf("ошибка создания каталога %s: %s", dirPath, err)
+		}
+
+		for f := 0; f < filesPerDir; f++ {
+			body := fmt.Sprintf("file-%d-%d", d, f)
+			if (count+1)%duplicateMod == 0 {
+				body = stressTreeDuplicateBody
+			}
+
+			filePath := filepath.Join(dirPath, fmt.Sprintf("file%d.txt", f))
+			if err := os.WriteFile(filePath, []byte(body), 0o644); err != nil {
+				tb.Fatalf("ошибка создания файла %s: %s", filePath, err)
+			}
+
+			count++
+		}
+	}
+
+	return count
+}
+
+// TestStartWatchStressSyntheticTree прогоняет StartWatch по синтетическому
+// дереву из 100_000 файлов и проверяет, что обход завершается (не зависает
+// на пулах воркеров или канале chanDupl) и находит дубликаты, разложенные
+// buildSyntheticTree по дереву.
+func TestStartWatchStressSyntheticTree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("пропуск стресс-теста на 100 000 файлов в режиме -short")
+	}
+
+	root := t.TempDir()
+	total := buildSyntheticTree(t, root, stressTreeDirs, stressTreeFilesPerDir, stressTreeDuplicateMod)
+
+	fDir, err := os.Open(root)
+	if err != nil {
+		t.Fatalf("ошибка открытия каталога %s: %s", root, err)
+	}
+	defer fDir.Close()
+
+	hLog := log.WithFields(log.Fields{"test": "stress"})
+
+	options := OptionsNew(false, false, int16(dirWorkerPoolSize()), AlgorithmFull, hLog)
+	options.OpSet(OpReport)
+
+	reporter := &collectingReporter{}
+	options.ReporterSet(reporter)
+
+	var wg sync.WaitGroup
+	if err := StartWatch(options, fDir, &wg); err != nil {
+		t.Fatalf("ошибка StartWatch: %s", err)
+	}
+
+	expectedDuplicates := total / stressTreeDuplicateMod
+	if expectedDuplicates < 2 {
+		t.Fatalf("некорректные параметры теста: ожидается как минимум 2 дубликата, получено %d", expectedDuplicates)
+	}
+
+	// Все файлы с содержимым stressTreeDuplicateBody — одна группа дубликатов
+	if len(reporter.entries) != 1 || len(reporter.entries[0].Paths) != expectedDuplicates {
+		t.Fatalf("ожидалась 1 группа из %d дубликатов, получено %d групп", expectedDuplicates, len(reporter.entries))
+	}
+}
+
+// collectingReporter запоминает entries, переданные в WriteReport
+type collectingReporter struct {
+	entries []ReportEntry
+}
+
+func (r *collectingReporter) WriteReport(header ReportHeader, entries []ReportEntry) error {
+	r.entries = entries
+	return nil
+}
+
+// TestPoolWideFanoutNoDeadlock воспроизводит сценарий, из-за которого Pool
+// был переписан на растущую очередь: один каталог с числом поддиректорий,
+// заведомо превышающим бывшую ёмкость очереди dirPool
+// (dirWorkerPoolSize()*16). С блокирующей отправкой в канал фиксированной
+// ёмкости воркер, разбирающий такой каталог, зависал в собственном
+// dirPool.Submit — разгрузить переполненную очередь было некому, кроме
+// воркеров того же пула, которые все были заняты тем же самым каталогом.
+func TestPoolWideFanoutNoDeadlock(t *testing.T) {
+	root := t.TempDir()
+	wideDir := filepath.Join(root, "wide")
+	if err := os.MkdirAll(wideDir, 0o755); err != nil {
+		t.Fatalf("ошибка создания каталога %s: %s", wideDir, err)
+	}
+
+	fanout := dirWorkerPoolSize()*16 + 1000
+	for i := 0; i < fanout; i++ {
+		sub := filepath.Join(wideDir, fmt.Sprintf("sub%d", i))
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			t.Fatalf("ошибка создания каталога %s: %s", sub, err)
+		}
+	}
+
+	fDir, err := os.Open(root)
+	if err != nil {
+		t.Fatalf("ошибка открытия каталога %s: %s", root, err)
+	}
+	defer fDir.Close()
+
+	hLog := log.WithFields(log.Fields{"test": "wide-fanout"})
+	options := OptionsNew(false, false, int16(dirWorkerPoolSize()), AlgorithmFull, hLog)
+
+	var wg sync.WaitGroup
+	done := make(chan error, 1)
+	go func() {
+		done <- StartWatch(options, fDir, &wg)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ошибка StartWatch: %s", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("StartWatch завис: воркер dirPool заблокировался, отправляя задания на поддиректории в собственную же очередь")
+	}
+}
+
+// TestMutatingOpRequiresNeedRemoveDuplicate проверяет, что мутирующие
+// операции (remove/hardlink/...) не применяются к найденным дубликатам, пока
+// пользователь явно не запросил обработку дубликатов (Options.NeedRemoveDuplicateGet,
+// флаг -r командной строки) — независимо от выбранного --op. Регрессия на
+// ./main --op=remove без -r, молча удалявшую дубликаты без подтверждения.
+func TestMutatingOpRequiresNeedRemoveDuplicate(t *testing.T) {
+	for _, op := range []Op{OpRemove, OpHardlink} {
+		op := op
+
+		t.Run(op.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			body := []byte("одинаковое содержимое")
+
+			original := filepath.Join(dir, "a.txt")
+			duplicate := filepath.Join(dir, "b.txt")
+
+			if err := os.WriteFile(original, body, 0o644); err != nil {
+				t.Fatalf("ошибка записи файла %s: %s", original, err)
+			}
+			if err := os.WriteFile(duplicate, body, 0o644); err != nil {
+				t.Fatalf("ошибка записи файла %s: %s", duplicate, err)
+			}
+
+			fDir, err := os.Open(dir)
+			if err != nil {
+				t.Fatalf("ошибка открытия каталога %s: %s", dir, err)
+			}
+			defer fDir.Close()
+
+			hLog := log.WithFields(log.Fields{"test": "mutating-op-guard"})
+			// needRemoveDuplicate (второй аргумент) сознательно false — как
+			// при запуске ./main --op=remove без флага -r
+			options := OptionsNew(false, false, int16(dirWorkerPoolSize()), AlgorithmFull, hLog)
+			options.OpSet(op)
+
+			var wg sync.WaitGroup
+			if err := StartWatch(options, fDir, &wg); err != nil {
+				t.Fatalf("ошибка StartWatch: %s", err)
+			}
+
+			stats := make([]os.FileInfo, 0, 2)
+			for _, p := range []string{original, duplicate} {
+				st, err := os.Stat(p)
+				if err != nil {
+					t.Fatalf("файл %s не должен был быть изменён без -r (needRemoveDuplicate): %s", p, err)
+				}
+				stats = append(stats, st)
+			}
+			// Дубликат, заменённый жёсткой ссылкой, по-прежнему существует —
+			// замену видно только по совпадению inode с оригиналом
+			if os.SameFile(stats[0], stats[1]) {
+				t.Fatalf("файл %s не должен был быть заменён ссылкой на %s без -r (needRemoveDuplicate)", duplicate, original)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Пакет progress описывает наблюдение за ходом сканирования — числом
+// просмотренных файлов, объёмом прохэшированных данных и оценкой оставшегося
+// времени (ETA), по мотивам ProgressTracker из fclones.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot — срез состояния прогресса на момент вызова Tracker.Snapshot
+type Snapshot struct {
+	FilesScanned int64
+	BytesHashed  int64
+	Elapsed      time.Duration
+	// ETA — оценка оставшегося времени, посчитанная по доле уже просмотренных
+	// файлов от totalFiles (см. Tracker.SetTotalFiles). Нулевое значение,
+	// если totalFiles не задан или ещё не просмотрено ни одного файла
+	ETA time.Duration
+}
+
+// Tracker получает события о ходе сканирования. Реализации должны быть
+// безопасны для вызова из нескольких горутин одновременно — сканирование
+// идёт в пуле воркеров (см. process.Pool)
+type Tracker interface {
+	// FileScanned увеличивает счётчик просмотренных файлов на один
+	FileScanned()
+	// BytesHashed увеличивает счётчик прохэшированных байт на n
+	BytesHashed(n int64)
+	// SetTotalFiles задаёт ожидаемое общее число файлов для расчёта ETA.
+	// 0 означает, что общее число неизвестно и ETA не считается
+	SetTotalFiles(total int64)
+	// Snapshot возвращает текущее состояние прогресса
+	Snapshot() Snapshot
+}
+
+// counterTracker — базовая реализация Tracker: только счётчики, без вывода
+type counterTracker struct {
+	filesScanned int64
+	bytesHashed  int64
+	totalFiles   int64
+	startedAt    time.Time
+}
+
+// NewCounterTracker возвращает Tracker, который только считает события и
+// ничего не выводит — подходит для неинтерактивного запуска (например,
+// когда отчёт формируется в файл, а не в терминал)
+func NewCounterTracker(startedAt time.Time) Tracker {
+	return &counterTracker{startedAt: startedAt}
+}
+
+func (t *counterTracker) FileScanned() {
+	atomic.AddInt64(&t.filesScanned, 1)
+}
+
+func (t *counterTracker) BytesHashed(n int64) {
+	atomic.AddInt64(&t.bytesHashed, n)
+}
+
+func (t *counterTracker) SetTotalFiles(total int64) {
+	atomic.StoreInt64(&t.totalFiles, total)
+}
+
+func (t *counterTracker) Snapshot() Snapshot {
+	s := Snapshot{
+		FilesScanned: atomic.LoadInt64(&t.filesScanned),
+		BytesHashed:  atomic.LoadInt64(&t.bytesHashed),
+		Elapsed:      time.Since(t.startedAt),
+	}
+
+	total := atomic.LoadInt64(&t.totalFiles)
+	if total > 0 && s.FilesScanned > 0 && s.FilesScanned < total {
+		perFile := s.Elapsed / time.Duration(s.FilesScanned)
+		s.ETA = perFile * time.Duration(total-s.FilesScanned)
+	}
+
+	return s
+}
+
+// barTracker оборачивает counterTracker и после каждого FileScanned
+// перерисовывает однострочный прогресс-бар в w (обычно — os.Stderr)
+type barTracker struct {
+	Tracker
+	w io.Writer
+}
+
+// NewBarTracker возвращает Tracker, который вдобавок к подсчёту выводит в w
+// однострочный прогресс вида "Просканировано файлов: N, ...", перерисовывая
+// его на каждый FileScanned через возврат каретки
+func NewBarTracker(w io.Writer, startedAt time.Time) Tracker {
+	return &barTracker{Tracker: NewCounterTracker(startedAt), w: w}
+}
+
+func (t *barTracker) FileScanned() {
+	t.Tracker.FileScanned()
+
+	s := t.Tracker.Snapshot()
+	if s.ETA > 0 {
+		fmt.Fprintf(t.w, "\rПросканировано файлов: %d, прохэшировано байт: %d, ETA: %s   ",
+			s.FilesScanned, s.BytesHashed, s.ETA.Round(time.Second))
+	} else {
+		fmt.Fprintf(t.w, "\rПросканировано файлов: %d, прохэшировано байт: %d   ",
+			s.FilesScanned, s.BytesHashed)
+	}
+}
@@ -0,0 +1,204 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Version — версия инструмента, попадающая в ReportHeader
+const Version = "0.1.0"
+
+// ReportEntry описывает одну группу найденных дубликатов: Paths[0] всегда
+// совпадает с KeptPath и является оставляемым оригиналом. Digest — хэш
+// оригинала: в обычном режиме это FindDuplicate.Digest (blake3), а
+// в Options.BlockModeGet(), где FindDuplicate.Digest не заполняется, —
+// представительный хэш, построенный по блокам (см. blockDigest).
+type ReportEntry struct {
+	GroupID     int
+	Size        int64
+	Digest      []byte
+	Paths       []string
+	KeptPath    string
+	WastedBytes int64
+}
+
+// ReportHeader описывает контекст отчёта о найденных дубликатах
+type ReportHeader struct {
+	ToolVersion      string
+	ScanRoots        []string
+	Timestamp        time.Time
+	TotalWastedBytes int64
+}
+
+// Reporter выводит итог сканирования (ReportHeader и список ReportEntry) в
+// конкретном формате — см. JSONReporter, CSVReporter, FdupesReporter.
+// StartDuplicateFind вызывает WriteReport один раз, по завершении сканирования.
+type Reporter interface {
+	WriteReport(header ReportHeader, entries []ReportEntry) error
+}
+
+// newReportEntries строит по одному ReportEntry на каждую группу дубликатов,
+// считая первый файл группы (dupGroup[0]) оставляемым оригиналом. hLog
+// используется только для логирования ошибки blockDigest — отсутствие
+// digest не прерывает формирование отчёта.
+func newReportEntries(hLog *logrus.Entry, dupGroups [][]FindDuplicate) []ReportEntry {
+	entries := make([]ReportEntry, 0, len(dupGroups))
+
+	for i, dupGroup := range dupGroups {
+		paths := make([]string, len(dupGroup))
+		for j, fd := range dupGroup {
+			paths[j] = fd.DirName + "/" + fd.FileName
+		}
+
+		digest := dupGroup[0].Digest
+		if digest == nil && len(dupGroup[0].Blocks) > 0 {
+			blockDigest, err := blockDigest(dupGroup[0])
+			if err != nil {
+				hLog.WithFields(logrus.Fields{"newReportEntries": ""}).Errorf("ошибка вычисления digest по блокам для %s: %s", paths[0], err)
+			} else {
+				digest = blockDigest
+			}
+		}
+
+		entries = append(entries, ReportEntry{
+			GroupID:     i,
+			Size:        dupGroup[0].FileSize,
+			Digest:      digest,
+			Paths:       paths,
+			KeptPath:    paths[0],
+			WastedBytes: int64(len(paths)-1) * dupGroup[0].FileSize,
+		})
+	}
+
+	return entries
+}
+
+// blockDigest строит представительный хэш группы, сравненной поблочно
+// (Options.BlockModeGet) — в этом режиме FindDuplicate.Digest не
+// заполняется, поэтому для отчёта digest считается отдельно: SHA-256 от
+// конкатенации строгих хэшей всех блоков файла (см. blocks.BlockInfo.StrongHash)
+func blockDigest(fd FindDuplicate) ([]byte, error) {
+	sFilePath := fd.DirName + "/" + fd.FileName
+
+	h := sha256.New()
+	for i := range fd.Blocks {
+		strong, err := fd.Blocks[i].StrongHash(sFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		h.Write(strong)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// newReportHeader строит ReportHeader для entries и заданных корней сканирования
+func newReportHeader(scanRoots []string, entries []ReportEntry) ReportHeader {
+	var totalWastedBytes int64
+	for _, e := range entries {
+		totalWastedBytes += e.WastedBytes
+	}
+
+	return ReportHeader{
+		ToolVersion:      Version,
+		ScanRoots:        scanRoots,
+		Timestamp:        time.Now(),
+		TotalWastedBytes: totalWastedBytes,
+	}
+}
+
+// JSONReporter пишет отчёт одним JSON-объектом {header, entries}
+type JSONReporter struct {
+	w io.Writer
+}
+
+// NewJSONReporter возвращает Reporter, пишущий отчёт в формате JSON в w
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{w: w}
+}
+
+func (r *JSONReporter) WriteReport(header ReportHeader, entries []ReportEntry) error {
+	return json.NewEncoder(r.w).Encode(struct {
+		Header  ReportHeader  `json:"header"`
+		Entries []ReportEntry `json:"entries"`
+	}{header, entries})
+}
+
+// CSVReporter пишет отчёт в виде CSV-таблицы, по одной строке на файл
+type CSVReporter struct {
+	w io.Writer
+}
+
+// NewCSVReporter возвращает Reporter, пишущий отчёт в формате CSV в w
+func NewCSVReporter(w io.Writer) *CSVReporter {
+	return &CSVReporter{w: w}
+}
+
+func (r *CSVReporter) WriteReport(header ReportHeader, entries []ReportEntry) error {
+	cw := csv.NewWriter(r.w)
+
+	if err := cw.Write([]string{"group_id", "size", "digest", "kept_path", "path", "wasted_bytes"}); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		sDigest := hex.EncodeToString(e.Digest)
+
+		for _, sPath := range e.Paths {
+			row := []string{
+				strconv.Itoa(e.GroupID),
+				strconv.FormatInt(e.Size, 10),
+				sDigest,
+				e.KeptPath,
+				sPath,
+				strconv.FormatInt(e.WastedBytes, 10),
+			}
+
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// FdupesReporter пишет отчёт в формате fdupes: каждая группа дубликатов —
+// это список путей, по одному на строку, группы разделены пустой строкой
+type FdupesReporter struct {
+	w io.Writer
+}
+
+// NewFdupesReporter возвращает Reporter, пишущий отчёт в формате fdupes в w
+func NewFdupesReporter(w io.Writer) *FdupesReporter {
+	return &FdupesReporter{w: w}
+}
+
+func (r *FdupesReporter) WriteReport(header ReportHeader, entries []ReportEntry) error {
+	for i, e := range entries {
+		if i > 0 {
+			if _, err := fmt.Fprintln(r.w); err != nil {
+				return err
+			}
+		}
+
+		for _, sPath := range e.Paths {
+			if _, err := fmt.Fprintln(r.w, sPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package process
+
+import (
+	"os"
+	"strings"
+	"syscall"
+
+	"FindDuplicate/process/filter"
+)
+
+// loadDirMatcher объединяет родительский matcher с правилами
+// filter.FdignoreFileName директории sDirPath, если такой файл там есть.
+func loadDirMatcher(parent *filter.Matcher, sDirPath string) (*filter.Matcher, error) {
+	local, err := filter.LoadMatcherFile(sDirPath + "/" + filter.FdignoreFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return parent.Merge(local), nil
+}
+
+// relPathFromRoots обрезает sFullPath до пути относительно первого корня
+// сканирования (см. Options.ScanRootsGet), чтобы Matcher сравнивал путь так
+// же, как .gitignore — от корня проекта, а не от абсолютного пути на диске.
+func relPathFromRoots(scanRoots []string, sFullPath string) string {
+	if len(scanRoots) == 0 {
+		return sFullPath
+	}
+
+	rel := strings.TrimPrefix(sFullPath, scanRoots[0])
+
+	return strings.TrimPrefix(rel, "/")
+}
+
+// statDevIno возвращает (dev, ino, true), если для st доступны номер
+// устройства и inode (syscall.Stat_t) — используется для определения точек
+// монтирования (Options.CrossFilesystemsGet) и защиты от зацикливания на
+// симлинках (Options.markInodeVisited).
+func statDevIno(st os.FileInfo) (uint64, uint64, bool) {
+	stat, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}